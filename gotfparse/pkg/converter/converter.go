@@ -5,8 +5,8 @@ package converter
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"sort"
+	"strings"
 
 	"github.com/Jeffail/gabs/v2"
 	"github.com/aquasecurity/trivy/pkg/iac/scanners/terraform/parser"
@@ -15,8 +15,6 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
-var logger = log.New(os.Stderr, "converter", 1)
-
 type stringSet map[string]bool
 
 func (s *stringSet) Add(str string) {
@@ -43,6 +41,18 @@ type terraformConverter struct {
 	countsByParentPathBlockName map[string]map[string]int
 
 	blocksByReference map[string]*terraform.Block
+
+	contributorsByReference map[string]stringSet
+
+	dynamicMetaByReference map[string]dynamicBlockMeta
+
+	diagnostics        []Diagnostic
+	diagnosticsHandler func(Diagnostic)
+	aborted            bool
+
+	recordedFiles map[string][]byte
+
+	modulePathByModule map[*terraform.Module]string
 }
 
 // VisitJSON visits each of the Terraform JSON blocks that the Terraform converter
@@ -51,8 +61,25 @@ type terraformConverter struct {
 func (t *terraformConverter) VisitJSON() *gabs.Container {
 	jsonOut := gabs.New()
 
+	// aborted is scoped to this call: a prior Snapshot() call may have
+	// already flipped it as a side effect of getModulePath recording an
+	// ambiguous-module-path diagnostic, and that shouldn't silently
+	// truncate an unrelated, later VisitJSON call.
+	t.aborted = false
+
+	t.ResolveGlobalReferences()
+
+	jsonOut.SetP(t.ModuleInventory(), "__module")
+
 	for _, m := range t.modules {
 		t.visitModule(m, jsonOut)
+		if t.aborted {
+			break
+		}
+	}
+
+	if len(t.diagnostics) > 0 {
+		jsonOut.SetP(t.diagnostics, "__diagnostics")
 	}
 
 	return jsonOut
@@ -61,9 +88,15 @@ func (t *terraformConverter) VisitJSON() *gabs.Container {
 // visitModule takes a module and walks each of the blocks underneath it.
 func (t *terraformConverter) visitModule(m *terraform.Module, out *gabs.Container) {
 	path := t.getModulePath(m)
+	if t.aborted {
+		return
+	}
 
 	for _, b := range m.GetBlocks() {
 		t.visitBlock(b, path, out)
+		if t.aborted {
+			return
+		}
 	}
 }
 
@@ -94,7 +127,16 @@ func (t *terraformConverter) visitBlock(b *terraform.Block, parentPath string, j
 
 		jsonOut.ArrayAppendP(json, key)
 	default:
-		logger.Printf("unknown block type: %s", b.Type())
+		r := b.GetMetadata().Range()
+		t.addDiagnostic(Diagnostic{
+			Severity:  SeverityWarning,
+			Summary:   "unknown block type",
+			Detail:    fmt.Sprintf("block type %q is not handled by the converter and was skipped", b.Type()),
+			Filename:  r.GetLocalFilename(),
+			LineStart: r.GetStartLine(),
+			LineEnd:   r.GetEndLine(),
+			BlockPath: parentPath,
+		})
 	}
 }
 
@@ -143,8 +185,11 @@ func (t *terraformConverter) buildBlock(b *terraform.Block) map[string]interface
 	obj := make(map[string]interface{})
 
 	add, dump := newBlockCollector()
-	for _, child := range getChildBlocks(b) {
+	for _, child := range t.getChildBlocks(b) {
 		key := child.Type()
+		if dm, ok := t.dynamicMetaByReference[child.Reference().String()]; ok && dm.targetType != "" {
+			key = dm.targetType
+		}
 		add(key, t.buildBlock(child))
 	}
 	grouped := dump()
@@ -158,7 +203,19 @@ func (t *terraformConverter) buildBlock(b *terraform.Block) map[string]interface
 		if b.Type() == "variable" && attrName == "type" {
 			// for variable type, the plain value is nil (unless the type has
 			// been provided in quotes), look at the variable type instead
-			var_type, _, _ := a.DecodeVarType()
+			var_type, _, err := a.DecodeVarType()
+			if err != nil {
+				r := b.GetMetadata().Range()
+				t.addDiagnostic(Diagnostic{
+					Severity:  SeverityWarning,
+					Summary:   "failed to decode variable type",
+					Detail:    err.Error(),
+					Filename:  r.GetLocalFilename(),
+					LineStart: r.GetStartLine(),
+					LineEnd:   r.GetEndLine(),
+					BlockPath: b.GetMetadata().String(),
+				})
+			}
 			obj[attrName] = var_type.FriendlyName()
 		} else {
 			obj[attrName] = t.getAttributeValue(a)
@@ -182,6 +239,19 @@ func (t *terraformConverter) buildBlock(b *terraform.Block) map[string]interface
 	if refs := t.getAttributeRefsMeta(allRefs.Entries()); len(refs) > 0 {
 		meta["references"] = refs
 	}
+	if contributors := t.getContributorsMeta(b.Reference().String()); len(contributors) > 0 {
+		meta["contributors"] = contributors
+	}
+	if dm, ok := t.dynamicMetaByReference[b.Reference().String()]; ok {
+		meta["dynamic"] = true
+		meta["source_range"] = dm.sourceRange
+		if dm.iteratorKey != "" {
+			meta["iterator_key"] = dm.iteratorKey
+		}
+		if dm.expansion != "" {
+			meta["expansion"] = dm.expansion
+		}
+	}
 	if tl := b.TypeLabel(); tl != "" {
 		meta["label"] = tl
 	}
@@ -204,6 +274,27 @@ func (t *terraformConverter) getAttributeRefsMeta(refs []string) []map[string]an
 	return refsMeta
 }
 
+// getContributorsMeta looks up the resource/data blocks that transitively
+// contribute to the block identified by ref, as computed by
+// ResolveGlobalReferences, and renders them the same way getAttributeRefsMeta
+// renders direct references.
+func (t *terraformConverter) getContributorsMeta(ref string) []map[string]any {
+	contributorsMeta := [](map[string]any){}
+	for contributor := range t.contributorsByReference[ref] {
+		block, ok := t.blocksByReference[contributor]
+		if !ok {
+			continue
+		}
+		meta := map[string]any{
+			"id":    block.ID(),
+			"label": block.TypeLabel(),
+			"name":  block.NameLabel(),
+		}
+		contributorsMeta = append(contributorsMeta, meta)
+	}
+	return contributorsMeta
+}
+
 // getAttributeValue returns the value for the attribute
 func (t *terraformConverter) getAttributeValue(a *terraform.Attribute) any {
 	val := a.Value()
@@ -211,6 +302,12 @@ func (t *terraformConverter) getAttributeValue(a *terraform.Attribute) any {
 		return raw
 	}
 
+	t.addDiagnostic(Diagnostic{
+		Severity: SeverityWarning,
+		Summary:  "unsupported attribute value type",
+		Detail:   fmt.Sprintf("attribute %q has a value tfparse can't convert to a native JSON type; falling back to its raw expression", a.Name()),
+	})
+
 	return a.GetRawValue()
 }
 
@@ -276,67 +373,11 @@ func convertCtyToNativeValue(val cty.Value) (interface{}, bool) {
 	return nil, false
 }
 
-// getChildBlocks iterates over all children of a given `terraform.Block` and
-// returns a filtered list of the unique children. This is mostly here to avoid
-// issues with dynamic/content blocks.
-// For unknown reasons, dynamic blocks cause two issues:
-//   - the block with type 'dynamic' is a template, not a real resource, and
-//     should be skipped
-//   - blocks created by the template seem to be duplicated
-func getChildBlocks(b *terraform.Block) []*terraform.Block {
-	var (
-		expectedContentBlocks int
-
-		prevMaxEnd = 0
-		children   = make([]*terraform.Block, 0)
-	)
-
-	getForEachCount := func(b *terraform.Block) int {
-		attr := b.GetAttribute("for_each")
-
-		value := attr.Value()
-		if value.IsNull() {
-			return 0
-		}
-
-		slice := value.AsValueSlice()
-		return len(slice)
-	}
-
-	for _, block := range b.AllBlocks() {
-		// track dynamic blocks
-		if block.Type() == "dynamic" {
-			// no reason to track these, they're just templates
-			// track the expected values though
-			forEachCount := getForEachCount(block)
-			expectedContentBlocks += forEachCount
-			continue
-		}
-
-		// deal with normal blocks
-		blockRange := block.GetMetadata().Range()
-		start := blockRange.GetStartLine()
-		if start >= prevMaxEnd {
-			prevMaxEnd = blockRange.GetEndLine()
-			children = append(children, block)
-			continue
-		}
-
-		// once we start reprocessing previous blocks, assume
-		// they're instances of the dynamic templates
-		expectedContentBlocks--
-		if expectedContentBlocks > 0 {
-			children = append(children, block)
-			continue
-		}
-	}
-
-	return children
-}
-
 // NewTerraformConverter creates a new TerraformConverter.
 // A TerraformConverter loads the HCL from the filePath and parses it in to memory as "blocks".
 // These blocks get extrated as JSON structured data for use by other tools.
+// On a parse/evaluate error the returned converter is non-nil, so callers
+// can still retrieve the recorded Diagnostic via Diagnostics().
 func NewTerraformConverter(filePath string, opts ...TerraformConverterOption) (*terraformConverter, error) {
 	tfc := &terraformConverter{
 		filePath:      filePath,
@@ -346,25 +387,38 @@ func NewTerraformConverter(filePath string, opts ...TerraformConverterOption) (*
 
 		countsByParentPathBlockName: make(map[string]map[string]int),
 		blocksByReference:           make(map[string]*terraform.Block),
+		dynamicMetaByReference:      make(map[string]dynamicBlockMeta),
+		modulePathByModule:          make(map[*terraform.Module]string),
 	}
 
 	for _, opt := range opts {
 		opt(tfc)
 	}
 
-	fileSystem := newRelativeResolveFs(filePath)
+	fileSystem := newRecordingFS(newRelativeResolveFs(filePath))
 
 	p := parser.New(fileSystem, "", tfc.parserOptions...)
 	if err := p.ParseFS(context.TODO(), "."); err != nil {
-		return nil, err
+		tfc.addDiagnostic(Diagnostic{
+			Severity: SeverityError,
+			Summary:  "failed to parse Terraform configuration",
+			Detail:   err.Error(),
+		})
+		return tfc, err
 	}
 
 	m, _, err := p.EvaluateAll(context.TODO())
 	if err != nil {
-		return nil, err
+		tfc.addDiagnostic(Diagnostic{
+			Severity: SeverityError,
+			Summary:  "failed to evaluate Terraform configuration",
+			Detail:   err.Error(),
+		})
+		return tfc, err
 	}
 
 	tfc.modules = m
+	tfc.recordedFiles = fileSystem.recorded
 
 	return tfc, nil
 }
@@ -393,6 +447,12 @@ func (t *terraformConverter) SetTFVarsPaths(paths ...string) {
 	t.parserOptions = append(t.parserOptions, parser.OptionWithTFVarsPaths(paths...))
 }
 
+// SetStopOnError is a TerraformConverter option that causes VisitJSON to stop
+// visiting further blocks as soon as an Error-severity diagnostic is recorded.
+func (t *terraformConverter) SetStopOnError() {
+	t.stopOnError = true
+}
+
 func getModuleName(b *terraform.Block) string {
 	// This field is unexported, but necessary to generate the path of the
 	// module. Hopefully aquasecurity/defsec exports this in a future release.
@@ -412,8 +472,22 @@ func getModuleName(b *terraform.Block) string {
 
 // getModulePath gets a string describing the module's path, such as
 // "module.notify_slack_qa.module.lambda", which would refer to a module called
-// "lambda", which was included in a module called "notify_slack_qa"
+// "lambda", which was included in a module called "notify_slack_qa". The
+// result is cached per module so callers (VisitJSON, Snapshot) that ask for
+// the same module's path more than once don't re-emit the same diagnostic.
 func (t *terraformConverter) getModulePath(m *terraform.Module) string {
+	if path, ok := t.modulePathByModule[m]; ok {
+		return path
+	}
+
+	path := t.computeModulePath(m)
+	t.modulePathByModule[m] = path
+
+	return path
+}
+
+// computeModulePath does the actual work behind getModulePath.
+func (t *terraformConverter) computeModulePath(m *terraform.Module) string {
 	prefixes := make(map[string]struct{})
 	for _, b := range m.GetBlocks() {
 		moduleName := getModuleName(b)
@@ -422,15 +496,37 @@ func (t *terraformConverter) getModulePath(m *terraform.Module) string {
 		}
 	}
 
-	if len(prefixes) > 1 {
-		panic("found too many prefixes!")
+	names := make([]string, 0, len(prefixes))
+	for name := range prefixes {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	return t.resolveModulePrefixes(names)
+}
+
+// resolveModulePrefixes picks the module path given the distinct module-name
+// prefixes claimed by a module's blocks: the first one in sorted order. A well-formed module
+// should only ever claim one prefix; claiming more than one is recorded as a
+// SeverityError diagnostic rather than panicking, since it points at
+// surprising upstream parser behavior rather than anything this converter
+// can recover from cleanly.
+func (t *terraformConverter) resolveModulePrefixes(names []string) string {
+	sort.Strings(names)
 
-	for key := range prefixes {
-		return key
+	if len(names) > 1 {
+		t.addDiagnostic(Diagnostic{
+			Severity: SeverityError,
+			Summary:  "ambiguous module path",
+			Detail:   fmt.Sprintf("module contains blocks claiming more than one parent module prefix: %s", strings.Join(names, ", ")),
+		})
+	}
+
+	if len(names) == 0 {
+		return ""
 	}
 
-	return ""
+	return names[0]
 }
 
 // getPath returns a string describing the location of the block.
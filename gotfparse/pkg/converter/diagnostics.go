@@ -0,0 +1,60 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+// Severity classifies how serious a Diagnostic is.
+type Severity string
+
+const (
+	// SeverityError indicates the converter could not do what was asked and
+	// fell back to a degraded result (or, under SetStopOnError, stopped).
+	SeverityError Severity = "error"
+	// SeverityWarning indicates the converter produced a result, but had to
+	// skip or approximate something to do so.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a structured problem report raised while walking the parsed
+// Terraform blocks, in place of the stderr logging and panics the converter
+// used to resort to. It mirrors the tfdiags pattern used throughout the
+// Terraform codebase, giving library callers a source location to point
+// users at instead of scraped log output.
+type Diagnostic struct {
+	Severity  Severity `json:"severity"`
+	Summary   string   `json:"summary"`
+	Detail    string   `json:"detail,omitempty"`
+	Filename  string   `json:"filename,omitempty"`
+	LineStart int      `json:"line_start,omitempty"`
+	LineEnd   int      `json:"line_end,omitempty"`
+	BlockPath string   `json:"block_path,omitempty"`
+}
+
+// Diagnostics returns every diagnostic recorded so far.
+func (t *terraformConverter) Diagnostics() []Diagnostic {
+	return t.diagnostics
+}
+
+// addDiagnostic records d, streams it to the handler set via
+// WithDiagnosticsHandler if any, and, when SetStopOnError is in effect,
+// marks the converter so VisitJSON stops visiting further blocks after an
+// Error-severity diagnostic.
+func (t *terraformConverter) addDiagnostic(d Diagnostic) {
+	t.diagnostics = append(t.diagnostics, d)
+
+	if t.diagnosticsHandler != nil {
+		t.diagnosticsHandler(d)
+	}
+
+	if t.stopOnError && d.Severity == SeverityError {
+		t.aborted = true
+	}
+}
+
+// WithDiagnosticsHandler is a TerraformConverterOption that streams every
+// Diagnostic to handler as it's recorded, in addition to it being collected
+// for later retrieval via Diagnostics.
+func WithDiagnosticsHandler(handler func(Diagnostic)) TerraformConverterOption {
+	return func(t *terraformConverter) {
+		t.diagnosticsHandler = handler
+	}
+}
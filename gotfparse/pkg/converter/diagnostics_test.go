@@ -0,0 +1,102 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import "testing"
+
+// A module claiming more than one parent module prefix should never panic;
+// it's recorded as a SeverityError diagnostic instead (this used to panic
+// via "found too many prefixes!").
+func TestResolveModulePrefixesAmbiguous(t *testing.T) {
+	tfc := &terraformConverter{}
+
+	got := tfc.resolveModulePrefixes([]string{"module.b", "module.a"})
+
+	if got != "module.a" {
+		t.Errorf("resolveModulePrefixes returned %q, want the sorted-first prefix %q", got, "module.a")
+	}
+
+	diags := tfc.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d: %v", len(diags), diags)
+	}
+	if diags[0].Severity != SeverityError {
+		t.Errorf("expected SeverityError, got %q", diags[0].Severity)
+	}
+	if diags[0].Summary != "ambiguous module path" {
+		t.Errorf("unexpected diagnostic summary: %q", diags[0].Summary)
+	}
+}
+
+func TestResolveModulePrefixesUnambiguous(t *testing.T) {
+	tfc := &terraformConverter{}
+
+	got := tfc.resolveModulePrefixes([]string{"module.a"})
+
+	if got != "module.a" {
+		t.Errorf("resolveModulePrefixes returned %q, want %q", got, "module.a")
+	}
+	if len(tfc.Diagnostics()) != 0 {
+		t.Errorf("expected no diagnostics, got %v", tfc.Diagnostics())
+	}
+}
+
+// addDiagnostic only latches aborted for Error-severity diagnostics, and
+// only when SetStopOnError is in effect.
+func TestAddDiagnosticStopOnError(t *testing.T) {
+	tfc := &terraformConverter{stopOnError: true}
+	tfc.addDiagnostic(Diagnostic{Severity: SeverityWarning})
+	if tfc.aborted {
+		t.Errorf("a warning diagnostic should not abort")
+	}
+
+	tfc.addDiagnostic(Diagnostic{Severity: SeverityError})
+	if !tfc.aborted {
+		t.Errorf("an error diagnostic under SetStopOnError should abort")
+	}
+}
+
+func TestAddDiagnosticWithoutStopOnError(t *testing.T) {
+	tfc := &terraformConverter{}
+	tfc.addDiagnostic(Diagnostic{Severity: SeverityError})
+	if tfc.aborted {
+		t.Errorf("an error diagnostic should not abort unless SetStopOnError is set")
+	}
+}
+
+// A clean parse records no Error-severity diagnostic, so VisitJSON under
+// SetStopOnError runs to completion without setting aborted.
+func TestVisitJSONCleanParseDoesNotAbortUnderStopOnError(t *testing.T) {
+	conv, err := NewTerraformConverter("testdata/globalrefs")
+	if err != nil {
+		t.Fatalf("NewTerraformConverter: %v", err)
+	}
+	conv.stopOnError = true
+
+	out := conv.VisitJSON()
+	if out == nil {
+		t.Fatalf("VisitJSON returned nil")
+	}
+	if conv.aborted {
+		t.Errorf("a clean parse should not leave the converter aborted")
+	}
+}
+
+// aborted is scoped to a single VisitJSON call: a stale aborted flag left
+// over from something else (e.g. a prior Snapshot() call hitting the
+// ambiguous-module-path diagnostic) must not silently truncate a later,
+// unrelated VisitJSON call.
+func TestVisitJSONResetsAbortedFromPriorCall(t *testing.T) {
+	conv, err := NewTerraformConverter("testdata/globalrefs")
+	if err != nil {
+		t.Fatalf("NewTerraformConverter: %v", err)
+	}
+	conv.aborted = true
+
+	out := conv.VisitJSON()
+
+	if !out.ExistsP("test_resource") {
+		t.Errorf("expected VisitJSON to still produce output, not stop immediately: %v", out)
+	}
+	findResource(t, conv, "rule")
+}
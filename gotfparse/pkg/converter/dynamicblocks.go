@@ -0,0 +1,182 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import (
+	"regexp"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+)
+
+// dynamicBlockMeta describes how a child block emitted by getChildBlocks
+// relates to the `dynamic` block it was rendered from. buildBlock embeds
+// these fields as __tfmeta.dynamic, __tfmeta.iterator_key,
+// __tfmeta.source_range and __tfmeta.expansion.
+type dynamicBlockMeta struct {
+	targetType  string
+	iteratorKey string
+	sourceRange map[string]interface{}
+	expansion   string
+}
+
+// getChildBlocks iterates over all children of a given `terraform.Block` and
+// returns a filtered list of the unique children, expanding any `dynamic`
+// blocks it finds into one concrete instance per for_each iteration.
+//
+// This does not build on hcl/ext/dynblock. dynblock needs the raw hcl.Body
+// and hcl.EvalContext for a block to synthesize instances itself, but
+// terraform.Block (the only surface trivy's parser exposes here) already
+// hands back dynamic-block instances it rendered internally, with no access
+// to either. So rather than synthesizing children, this correlates trivy's
+// already-rendered siblings back to their `dynamic` template by shared
+// source range (see expandDynamicBlock) — a deliberate divergence from a
+// dynblock-based pipeline, forced by that abstraction boundary.
+func (t *terraformConverter) getChildBlocks(b *terraform.Block) []*terraform.Block {
+	all := b.AllBlocks()
+
+	claimed := make(map[*terraform.Block]bool)
+	expansions := make(map[*terraform.Block][]*terraform.Block)
+	for _, block := range all {
+		if block.Type() != "dynamic" {
+			continue
+		}
+		expansions[block] = t.expandDynamicBlock(block, all, claimed)
+	}
+
+	children := make([]*terraform.Block, 0, len(all))
+	for _, block := range all {
+		switch {
+		case block.Type() == "dynamic":
+			children = append(children, expansions[block]...)
+		case claimed[block]:
+			continue
+		default:
+			children = append(children, block)
+		}
+	}
+
+	return children
+}
+
+// expandDynamicBlock resolves the rendered instances of a single `dynamic
+// "foo" { for_each = ... content { ... } }` block: trivy's evaluator already
+// renders one sibling per for_each iteration, typed as "foo" and sharing the
+// exact source range of the `content` block it was rendered from, so renders
+// are matched to their template by that shared range rather than by
+// guessing from line order. Every claimed sibling is recorded in `claimed`
+// so getChildBlocks doesn't also emit it as an ordinary child.
+//
+// for_each count (not order) comes from dynamicIteratorCount; each
+// instance's own iterator key is read back off its rendered reference
+// string (e.g. `foo["bravo"]`), since trivy's render order for a map
+// for_each isn't guaranteed to match any independently-sorted key list.
+func (t *terraformConverter) expandDynamicBlock(dyn *terraform.Block, siblings []*terraform.Block, claimed map[*terraform.Block]bool) []*terraform.Block {
+	targetType := dyn.TypeLabel()
+
+	var template *terraform.Block
+	for _, content := range dyn.AllBlocks() {
+		if content.Type() == "content" {
+			template = content
+			break
+		}
+	}
+	if template == nil {
+		return nil
+	}
+
+	count, known := dynamicIteratorCount(dyn)
+	if !known {
+		t.recordDynamicMeta(template, template, targetType, "", "unknown")
+		return []*terraform.Block{template}
+	}
+
+	instances := make([]*terraform.Block, 0, count)
+	for _, sibling := range siblings {
+		if len(instances) >= count {
+			break
+		}
+		if sibling == template || sibling == dyn || claimed[sibling] || sibling.Type() != targetType {
+			continue
+		}
+		if !blocksShareRange(sibling, template) {
+			continue
+		}
+
+		claimed[sibling] = true
+		key, _ := iteratorKeyFromReference(sibling)
+		t.recordDynamicMeta(sibling, template, targetType, key, "")
+		instances = append(instances, sibling)
+	}
+
+	return instances
+}
+
+// recordDynamicMeta stashes the dynamic-expansion metadata for instance,
+// keyed by its reference string, using template's source range as the
+// "where this came from" pointer.
+func (t *terraformConverter) recordDynamicMeta(instance, template *terraform.Block, targetType, iteratorKey, expansion string) {
+	r := template.GetMetadata().Range()
+
+	t.dynamicMetaByReference[instance.Reference().String()] = dynamicBlockMeta{
+		targetType:  targetType,
+		iteratorKey: iteratorKey,
+		expansion:   expansion,
+		sourceRange: map[string]interface{}{
+			"filename":   r.GetLocalFilename(),
+			"line_start": r.GetStartLine(),
+			"line_end":   r.GetEndLine(),
+		},
+	}
+}
+
+// blocksShareRange reports whether a and b were parsed from the exact same
+// source range, which is how trivy's evaluator links a rendered dynamic
+// block instance back to the `content` block it was templated from.
+func blocksShareRange(a, b *terraform.Block) bool {
+	ra := a.GetMetadata().Range()
+	rb := b.GetMetadata().Range()
+
+	return ra.GetLocalFilename() == rb.GetLocalFilename() &&
+		ra.GetStartLine() == rb.GetStartLine() &&
+		ra.GetEndLine() == rb.GetEndLine()
+}
+
+// dynamicIteratorKeyPattern matches the trailing `["key"]` or `[0]` index
+// terraform appends to a block reference expanded by for_each/count.
+var dynamicIteratorKeyPattern = regexp.MustCompile(`\[(?:"([^"]*)"|(\d+))\]$`)
+
+// iteratorKeyFromReference reads the for_each iterator key back off a
+// rendered block's own reference string, rather than re-deriving it
+// independently from the for_each value.
+func iteratorKeyFromReference(block *terraform.Block) (string, bool) {
+	match := dynamicIteratorKeyPattern.FindStringSubmatch(block.Reference().String())
+	if match == nil {
+		return "", false
+	}
+	if match[1] != "" {
+		return match[1], true
+	}
+	return match[2], true
+}
+
+// dynamicIteratorCount evaluates a `dynamic` block's for_each attribute and
+// returns how many instances it should render. The second return value is
+// false when for_each isn't known at parse time.
+func dynamicIteratorCount(dyn *terraform.Block) (int, bool) {
+	value := dyn.GetAttribute("for_each").Value()
+	if value.IsNull() || !value.IsKnown() {
+		return 0, false
+	}
+
+	vType := value.Type()
+
+	if vType.IsObjectType() || vType.IsMapType() {
+		return len(value.AsValueMap()), true
+	}
+
+	if vType.IsListType() || vType.IsSetType() || vType.IsTupleType() {
+		return len(value.AsValueSlice()), true
+	}
+
+	return 0, false
+}
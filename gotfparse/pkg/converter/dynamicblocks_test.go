@@ -0,0 +1,78 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+)
+
+func findResource(t *testing.T, conv *terraformConverter, name string) *terraform.Block {
+	t.Helper()
+
+	for _, m := range conv.modules {
+		for _, b := range m.GetBlocks() {
+			if b.Type() == "resource" && b.TypeLabel() == "test_resource" && b.NameLabel() == name {
+				return b
+			}
+		}
+	}
+
+	t.Fatalf("test_resource.%s not found", name)
+	return nil
+}
+
+// A map for_each is not guaranteed to render in the key's sort order (or any
+// other order getChildBlocks can predict), so the iterator key must be read
+// back off each rendered instance rather than zipped positionally against an
+// independently sorted key list.
+func TestExpandDynamicBlockMapForEachIteratorKeyMatchesRenderedInstance(t *testing.T) {
+	conv, err := NewTerraformConverter("testdata/dynamic_blocks")
+	if err != nil {
+		t.Fatalf("NewTerraformConverter: %v", err)
+	}
+	conv.VisitJSON()
+
+	resource := findResource(t, conv, "map_for_each")
+	children := conv.getChildBlocks(resource)
+	if len(children) != 3 {
+		t.Fatalf("expected 3 rendered ingress blocks, got %d", len(children))
+	}
+
+	for _, child := range children {
+		meta, ok := conv.dynamicMetaByReference[child.Reference().String()]
+		if !ok {
+			t.Fatalf("missing dynamic metadata for %s", child.Reference().String())
+		}
+
+		renderedKey := child.GetAttribute("key").Value().AsString()
+		if meta.iteratorKey != renderedKey {
+			t.Errorf("iterator_key %q does not match rendered ingress.key %q", meta.iteratorKey, renderedKey)
+		}
+	}
+}
+
+// Nested dynamic blocks must expand independently at each level: the inner
+// "tag" dynamic block shouldn't be claimed as an instance of the outer
+// "ingress" dynamic block, or vice versa.
+func TestExpandDynamicBlockNestedDynamicBlocks(t *testing.T) {
+	conv, err := NewTerraformConverter("testdata/dynamic_blocks")
+	if err != nil {
+		t.Fatalf("NewTerraformConverter: %v", err)
+	}
+	conv.VisitJSON()
+
+	resource := findResource(t, conv, "list_for_each")
+	ingresses := conv.getChildBlocks(resource)
+	if len(ingresses) != 3 {
+		t.Fatalf("expected 3 rendered ingress blocks, got %d", len(ingresses))
+	}
+
+	for _, ingress := range ingresses {
+		tags := conv.getChildBlocks(ingress)
+		if len(tags) != 2 {
+			t.Errorf("expected 2 rendered tag blocks under %s, got %d", ingress.Reference().String(), len(tags))
+		}
+	}
+}
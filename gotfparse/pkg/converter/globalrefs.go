@@ -0,0 +1,76 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+// referenceGraph is a directed graph of block reference strings, where an
+// edge from A to B means that some attribute on block A (or, for
+// variable/local/module blocks, its value expression) refers to block B.
+type referenceGraph map[string]stringSet
+
+// addEdge records that "from" refers to "to".
+func (g referenceGraph) addEdge(from, to string) {
+	if _, ok := g[from]; !ok {
+		g[from] = stringSet{}
+	}
+	g[from].Add(to)
+}
+
+// ResolveGlobalReferences walks every block in every loaded module and
+// builds, for each block reference, the transitive set of resource/data
+// blocks reachable from it through var/local/module/output indirections.
+// The result is cached as contributorsByReference and embedded by buildBlock
+// as __tfmeta.contributors.
+func (t *terraformConverter) ResolveGlobalReferences() {
+	graph := referenceGraph{}
+
+	for _, m := range t.modules {
+		for _, b := range m.GetBlocks() {
+			ref := b.Reference().String()
+			t.blocksByReference[ref] = b
+
+			for _, a := range b.GetAttributes() {
+				for _, r := range a.AllReferences() {
+					graph.addEdge(ref, r.String())
+				}
+			}
+		}
+	}
+
+	contributors := make(map[string]stringSet, len(graph))
+	for ref := range graph {
+		contributors[ref] = t.collectContributors(ref, graph, stringSet{})
+	}
+
+	t.contributorsByReference = contributors
+}
+
+// collectContributors performs a DFS over the reference graph starting at
+// ref, returning the set of resource/data block references reachable from
+// it. Intermediate var/local/module/output nodes are followed but not
+// included in the result. visited guards against cycles.
+func (t *terraformConverter) collectContributors(ref string, graph referenceGraph, visited stringSet) stringSet {
+	result := stringSet{}
+
+	for next := range graph[ref] {
+		if visited[next] {
+			continue
+		}
+		visited.Add(next)
+
+		block, ok := t.blocksByReference[next]
+		if !ok {
+			continue
+		}
+
+		switch block.Type() {
+		case "resource", "data":
+			result.Add(next)
+		default:
+			for contributor := range t.collectContributors(next, graph, visited) {
+				result.Add(contributor)
+			}
+		}
+	}
+
+	return result
+}
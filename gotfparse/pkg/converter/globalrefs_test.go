@@ -0,0 +1,25 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import "testing"
+
+// Contributors must chase through a module call boundary: rule's target
+// comes from local.endpoint, which comes from module.child.endpoint, which
+// comes from the child module's own resource block.
+func TestResolveGlobalReferencesCrossesModuleBoundary(t *testing.T) {
+	conv, err := NewTerraformConverter("testdata/globalrefs")
+	if err != nil {
+		t.Fatalf("NewTerraformConverter: %v", err)
+	}
+	conv.VisitJSON()
+
+	rule := findResource(t, conv, "rule")
+	backend := findResource(t, conv, "backend")
+
+	contributors := conv.contributorsByReference[rule.Reference().String()]
+	if !contributors[backend.Reference().String()] {
+		t.Errorf("expected contributors of %s to include %s, got %v",
+			rule.Reference().String(), backend.Reference().String(), contributors)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import (
+	"github.com/aquasecurity/trivy/pkg/iac/terraform"
+)
+
+// ModuleInventory summarizes what the root module declares: provider
+// requirements, variables, outputs, and module calls.
+type ModuleInventory struct {
+	RequiredCore      []string               `json:"required_core,omitempty"`
+	RequiredProviders map[string]ProviderReq `json:"required_providers,omitempty"`
+	Variables         []VariableSummary      `json:"variables,omitempty"`
+	Outputs           []OutputSummary        `json:"outputs,omitempty"`
+	ModuleCalls       []ModuleCallSummary    `json:"module_calls,omitempty"`
+}
+
+// ProviderReq describes a single entry of a `required_providers` block.
+type ProviderReq struct {
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// VariableSummary describes a declared `variable` block.
+type VariableSummary struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Default     any    `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	Sensitive   bool   `json:"sensitive,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+}
+
+// OutputSummary describes a declared `output` block.
+type OutputSummary struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Sensitive   bool     `json:"sensitive,omitempty"`
+	References  []string `json:"references,omitempty"`
+}
+
+// ModuleCallSummary describes a `module` call.
+type ModuleCallSummary struct {
+	Name    string   `json:"name"`
+	Source  string   `json:"source,omitempty"`
+	Version string   `json:"version,omitempty"`
+	Inputs  []string `json:"inputs,omitempty"`
+}
+
+// ModuleInventory walks the root module's top-level blocks once and returns a
+// structured summary of what it declares.
+func (t *terraformConverter) ModuleInventory() *ModuleInventory {
+	inventory := &ModuleInventory{
+		RequiredProviders: map[string]ProviderReq{},
+	}
+
+	if len(t.modules) == 0 {
+		return inventory
+	}
+
+	for _, b := range t.modules[0].GetBlocks() {
+		switch b.Type() {
+		case "terraform":
+			addTerraformSettings(b, inventory)
+		case "variable":
+			inventory.Variables = append(inventory.Variables, buildVariableSummary(b))
+		case "output":
+			inventory.Outputs = append(inventory.Outputs, buildOutputSummary(b))
+		case "module":
+			inventory.ModuleCalls = append(inventory.ModuleCalls, buildModuleCallSummary(b))
+		}
+	}
+
+	return inventory
+}
+
+// addTerraformSettings extracts required_version and required_providers from
+// a `terraform` block into inventory.
+func addTerraformSettings(b *terraform.Block, inventory *ModuleInventory) {
+	if v := b.GetAttribute("required_version").Value(); !v.IsNull() {
+		inventory.RequiredCore = append(inventory.RequiredCore, v.AsString())
+	}
+
+	for _, child := range b.AllBlocks() {
+		if child.Type() != "required_providers" {
+			continue
+		}
+
+		for _, attr := range child.GetAttributes() {
+			req := ProviderReq{}
+
+			if raw, ok := convertCtyToNativeValue(attr.Value()); ok {
+				switch v := raw.(type) {
+				case string:
+					req.Version = v
+				case map[string]interface{}:
+					if source, ok := v["source"].(string); ok {
+						req.Source = source
+					}
+					if version, ok := v["version"].(string); ok {
+						req.Version = version
+					}
+				}
+			}
+
+			inventory.RequiredProviders[attr.Name()] = req
+		}
+	}
+}
+
+// buildVariableSummary extracts the user-facing fields of a `variable` block.
+func buildVariableSummary(b *terraform.Block) VariableSummary {
+	summary := VariableSummary{Name: b.TypeLabel(), Nullable: true}
+
+	// type is optional, so only decode it when present (same guard buildBlock uses).
+	for _, attr := range b.GetAttributes() {
+		if attr.Name() != "type" {
+			continue
+		}
+		if varType, _, err := attr.DecodeVarType(); err == nil {
+			summary.Type = varType.FriendlyName()
+		}
+		break
+	}
+
+	if v := b.GetAttribute("default").Value(); !v.IsNull() {
+		if raw, ok := convertCtyToNativeValue(v); ok {
+			summary.Default = raw
+		}
+	}
+
+	if v := b.GetAttribute("description").Value(); !v.IsNull() {
+		summary.Description = v.AsString()
+	}
+
+	if v := b.GetAttribute("sensitive").Value(); !v.IsNull() {
+		summary.Sensitive = v.True()
+	}
+
+	if v := b.GetAttribute("nullable").Value(); !v.IsNull() {
+		summary.Nullable = v.True()
+	}
+
+	return summary
+}
+
+// buildOutputSummary extracts the user-facing fields of an `output` block.
+func buildOutputSummary(b *terraform.Block) OutputSummary {
+	summary := OutputSummary{Name: b.TypeLabel()}
+
+	if v := b.GetAttribute("description").Value(); !v.IsNull() {
+		summary.Description = v.AsString()
+	}
+
+	if v := b.GetAttribute("sensitive").Value(); !v.IsNull() {
+		summary.Sensitive = v.True()
+	}
+
+	for _, ref := range b.GetAttribute("value").AllReferences() {
+		summary.References = append(summary.References, ref.String())
+	}
+
+	return summary
+}
+
+// buildModuleCallSummary extracts the user-facing fields of a `module` call,
+// including the names of every input argument it sets.
+func buildModuleCallSummary(b *terraform.Block) ModuleCallSummary {
+	summary := ModuleCallSummary{Name: b.TypeLabel()}
+
+	if v := b.GetAttribute("source").Value(); !v.IsNull() {
+		summary.Source = v.AsString()
+	}
+
+	if v := b.GetAttribute("version").Value(); !v.IsNull() {
+		summary.Version = v.AsString()
+	}
+
+	for _, attr := range b.GetAttributes() {
+		switch attr.Name() {
+		case "source", "version", "providers", "count", "for_each", "depends_on":
+			continue
+		}
+		summary.Inputs = append(summary.Inputs, attr.Name())
+	}
+
+	return summary
+}
@@ -0,0 +1,79 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import "testing"
+
+func TestModuleInventory(t *testing.T) {
+	conv, err := NewTerraformConverter("testdata/module_inventory")
+	if err != nil {
+		t.Fatalf("NewTerraformConverter: %v", err)
+	}
+
+	inventory := conv.ModuleInventory()
+
+	if len(inventory.RequiredCore) != 1 || inventory.RequiredCore[0] != ">= 1.0" {
+		t.Errorf("unexpected RequiredCore: %v", inventory.RequiredCore)
+	}
+
+	aws, ok := inventory.RequiredProviders["aws"]
+	if !ok || aws.Version != "~> 4.0" || aws.Source != "" {
+		t.Errorf("unexpected aws provider requirement: %+v", aws)
+	}
+
+	random, ok := inventory.RequiredProviders["random"]
+	if !ok || random.Source != "hashicorp/random" || random.Version != "~> 3.0" {
+		t.Errorf("unexpected random provider requirement: %+v", random)
+	}
+
+	var region, instanceCount *VariableSummary
+	for i, v := range inventory.Variables {
+		switch v.Name {
+		case "region":
+			region = &inventory.Variables[i]
+		case "instance_count":
+			instanceCount = &inventory.Variables[i]
+		}
+	}
+	if region == nil {
+		t.Fatalf("variable.region not found")
+	}
+	if region.Type != "" {
+		t.Errorf("expected no decoded type for variable.region (type omitted), got %q", region.Type)
+	}
+	if region.Default != "us-east-1" {
+		t.Errorf("unexpected default for variable.region: %v", region.Default)
+	}
+	if !region.Nullable {
+		t.Errorf("expected variable.region to default to nullable=true")
+	}
+
+	if instanceCount == nil {
+		t.Fatalf("variable.instance_count not found")
+	}
+	if instanceCount.Type != "number" {
+		t.Errorf("expected variable.instance_count type %q, got %q", "number", instanceCount.Type)
+	}
+
+	if len(inventory.Outputs) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(inventory.Outputs))
+	}
+	regionOut := inventory.Outputs[0]
+	if regionOut.Name != "region_out" || regionOut.Description != "the region in use" {
+		t.Errorf("unexpected output summary: %+v", regionOut)
+	}
+	if len(regionOut.References) != 1 || regionOut.References[0] != "var.region" {
+		t.Errorf("expected output.region_out to reference var.region, got %v", regionOut.References)
+	}
+
+	if len(inventory.ModuleCalls) != 1 {
+		t.Fatalf("expected 1 module call, got %d", len(inventory.ModuleCalls))
+	}
+	network := inventory.ModuleCalls[0]
+	if network.Name != "network" || network.Source != "./network" || network.Version != "1.2.3" {
+		t.Errorf("unexpected module call summary: %+v", network)
+	}
+	if len(network.Inputs) != 1 || network.Inputs[0] != "region" {
+		t.Errorf("expected module.network inputs to be [region] (count/version/source excluded), got %v", network.Inputs)
+	}
+}
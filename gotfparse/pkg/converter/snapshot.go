@@ -0,0 +1,197 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"testing/fstest"
+)
+
+// SnapshotFile is a single file captured into a Snapshot, alongside the
+// sha256 of its contents so a later re-parse can be verified byte-for-byte.
+type SnapshotFile struct {
+	Filename string `json:"filename"`
+	SHA256   string `json:"sha256"`
+	bytes    []byte
+}
+
+// Snapshot is a reproducible bundle of the .tf/.tf.json files a
+// TerraformConverter loaded while parsing, grouped by the address of the
+// module that read it ("" for the root module). Only files that declare at
+// least one block are included; .tfvars files and files without blocks are
+// not.
+type Snapshot struct {
+	Files map[string][]SnapshotFile
+}
+
+// manifestEntry is the per-module-address record written to manifest.json at
+// the root of the zip produced by WriteZip.
+type manifestEntry struct {
+	ModuleAddress string         `json:"module_address"`
+	Files         []SnapshotFile `json:"files"`
+}
+
+// Snapshot captures every file the converter's parser read that declares at
+// least one block of its modules, grouped by the module address that read
+// it; files the parser read that declare no block (such as .tfvars files)
+// are not captured. Files are
+// attributed per module instance (not per source directory), since two
+// module calls can share a source directory.
+func (t *terraformConverter) Snapshot() (*Snapshot, error) {
+	snapshot := &Snapshot{Files: map[string][]SnapshotFile{}}
+
+	filenamesByAddress := map[string]stringSet{}
+	for _, m := range t.modules {
+		address := t.getModulePath(m)
+
+		names, ok := filenamesByAddress[address]
+		if !ok {
+			names = stringSet{}
+			filenamesByAddress[address] = names
+		}
+
+		for _, b := range m.GetBlocks() {
+			names.Add(b.GetMetadata().Range().GetLocalFilename())
+		}
+	}
+
+	for address, names := range filenamesByAddress {
+		for filename := range names {
+			contents, ok := t.recordedFiles[filename]
+			if !ok {
+				continue
+			}
+
+			sum := sha256.Sum256(contents)
+			snapshot.Files[address] = append(snapshot.Files[address], SnapshotFile{
+				Filename: filename,
+				SHA256:   hex.EncodeToString(sum[:]),
+				bytes:    contents,
+			})
+		}
+	}
+
+	for address := range snapshot.Files {
+		files := snapshot.Files[address]
+		sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	}
+
+	return snapshot, nil
+}
+
+// WriteZip serializes the snapshot to w as a zip archive: every captured
+// file at its original path, plus a manifest.json listing each module
+// address's files and their sha256 sums.
+func (s *Snapshot) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	manifest := make([]manifestEntry, 0, len(s.Files))
+	for address, files := range s.Files {
+		manifest = append(manifest, manifestEntry{ModuleAddress: address, Files: files})
+
+		for _, f := range files {
+			fw, err := zw.Create(f.Filename)
+			if err != nil {
+				return fmt.Errorf("creating %s in snapshot zip: %w", f.Filename, err)
+			}
+			if _, err := fw.Write(f.bytes); err != nil {
+				return fmt.Errorf("writing %s to snapshot zip: %w", f.Filename, err)
+			}
+		}
+	}
+
+	sort.Slice(manifest, func(i, j int) bool { return manifest[i].ModuleAddress < manifest[j].ModuleAddress })
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling snapshot manifest: %w", err)
+	}
+
+	mw, err := zw.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("creating snapshot manifest: %w", err)
+	}
+	if _, err := mw.Write(manifestBytes); err != nil {
+		return fmt.Errorf("writing snapshot manifest: %w", err)
+	}
+
+	return zw.Close()
+}
+
+// LoadSnapshotZip reads a zip archive written by Snapshot.WriteZip back into
+// an in-memory fs.FS, suitable for feeding a fresh, deterministic re-parse.
+func LoadSnapshotZip(r *zip.Reader) (fs.FS, error) {
+	mapFS := fstest.MapFS{}
+
+	for _, f := range r.File {
+		if f.Name == "manifest.json" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("opening %s in snapshot zip: %w", f.Name, err)
+		}
+
+		contents, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from snapshot zip: %w", f.Name, err)
+		}
+
+		mapFS[f.Name] = &fstest.MapFile{Data: contents, Mode: 0o644}
+	}
+
+	return mapFS, nil
+}
+
+// recordingFS wraps an fs.FS and remembers the contents of every regular
+// file it successfully reads, keyed by the path it was opened with.
+type recordingFS struct {
+	fs.FS
+	recorded map[string][]byte
+}
+
+func newRecordingFS(inner fs.FS) *recordingFS {
+	return &recordingFS{FS: inner, recorded: map[string][]byte{}}
+}
+
+func (r *recordingFS) Open(name string) (fs.File, error) {
+	f, err := r.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return f, err
+	}
+
+	contents, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	r.recorded[name] = contents
+
+	return &recordedFile{Reader: bytes.NewReader(contents), info: info}, nil
+}
+
+// recordedFile is an fs.File backed by bytes already read off disk, handed
+// back in place of the original so the file can still be read normally.
+type recordedFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *recordedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *recordedFile) Close() error               { return nil }
@@ -0,0 +1,54 @@
+// Copyright The Cloud Custodian Authors.
+// SPDX-License-Identifier: Apache-2.0
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"testing"
+)
+
+func TestSnapshotWriteZipLoadSnapshotZipRoundTrip(t *testing.T) {
+	snapshot := &Snapshot{
+		Files: map[string][]SnapshotFile{
+			"": {
+				{Filename: "main.tf", bytes: []byte(`resource "test_resource" "root" {}`)},
+			},
+			"module.child": {
+				{Filename: "child/main.tf", bytes: []byte(`resource "test_resource" "child" {}`)},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+
+	loaded, err := LoadSnapshotZip(zr)
+	if err != nil {
+		t.Fatalf("LoadSnapshotZip: %v", err)
+	}
+
+	for _, files := range snapshot.Files {
+		for _, f := range files {
+			got, err := fs.ReadFile(loaded, f.Filename)
+			if err != nil {
+				t.Fatalf("reading %s back: %v", f.Filename, err)
+			}
+			if !bytes.Equal(got, f.bytes) {
+				t.Errorf("%s round-tripped to %q, want %q", f.Filename, got, f.bytes)
+			}
+		}
+	}
+
+	if _, err := fs.ReadFile(loaded, "manifest.json"); err == nil {
+		t.Errorf("expected manifest.json to be excluded from the loaded fs.FS")
+	}
+}